@@ -0,0 +1,305 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/plugin/pkg/scheduler/algorithm"
+	schedulerapi "k8s.io/kubernetes/plugin/pkg/scheduler/api"
+	"k8s.io/kubernetes/plugin/pkg/scheduler/metrics"
+	"k8s.io/kubernetes/plugin/pkg/scheduler/schedulercache"
+)
+
+// DefaultMaxPriority is the upper bound of the score range extenders are
+// normalized into before weighting, matching the in-tree priority functions.
+const DefaultMaxPriority = 10
+
+// ChainedExtender runs a set of algorithm.SchedulerExtender in sequence for
+// Filter (each extender narrows the node set the next one sees) and in
+// parallel for Prioritize (each extender's scores are normalized to
+// [0, MaxPriority] and weighted before being summed). It lets a cluster run
+// several extenders -- e.g. storage, GPU, topology -- side by side. Only the
+// Prioritize fan-out is bounded by the chain's timeout; Filter and
+// ProcessPreemption wait for each extender's call to return, with no deadline
+// of their own.
+type ChainedExtender struct {
+	extenders   []algorithm.SchedulerExtender
+	maxPriority int
+	timeout     time.Duration
+}
+
+// NewChainedExtender builds a ChainedExtender over extenders. timeout bounds
+// the whole Prioritize fan-out; individual extenders that exceed it are
+// dropped from the result rather than failing the cycle.
+func NewChainedExtender(extenders []algorithm.SchedulerExtender, maxPriority int, timeout time.Duration) *ChainedExtender {
+	if maxPriority <= 0 {
+		maxPriority = DefaultMaxPriority
+	}
+	return &ChainedExtender{
+		extenders:   extenders,
+		maxPriority: maxPriority,
+		timeout:     timeout,
+	}
+}
+
+// Filter runs each extender's Filter in sequence, feeding the surviving nodes
+// of one extender into the next. It stops early if an extender narrows the
+// candidate set to zero nodes.
+func (c *ChainedExtender) Filter(
+	pod *api.Pod,
+	nodes *api.NodeList,
+	nodeNameToInfo map[string]*schedulercache.NodeInfo,
+) (*api.NodeList, schedulerapi.FailedNodesMap, error) {
+	combinedFailedNodes := schedulerapi.FailedNodesMap{}
+	for _, extender := range c.extenders {
+		if !extender.IsInterested(pod) {
+			continue
+		}
+
+		start := time.Now()
+		filtered, failedNodes, err := extender.Filter(pod, nodes, nodeNameToInfo)
+		metrics.ExtenderLatency.WithLabelValues("filter").Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.ExtenderError.WithLabelValues("filter").Inc()
+			if extender.IsIgnorable() {
+				continue
+			}
+			return nil, nil, err
+		}
+
+		for failedNodeName, reason := range failedNodes {
+			combinedFailedNodes[failedNodeName] = reason
+		}
+		nodes = filtered
+		if len(nodes.Items) == 0 {
+			break
+		}
+	}
+	return nodes, combinedFailedNodes, nil
+}
+
+// extenderPriorityResult is the per-extender outcome gathered by Prioritize
+// before it is folded into the combined score.
+type extenderPriorityResult struct {
+	extender algorithm.SchedulerExtender
+	result   *schedulerapi.HostPriorityList
+	weight   int
+}
+
+// Prioritize runs every extender's Prioritize concurrently, each bounded by
+// the ChainedExtender's global timeout, normalizes each extender's scores to
+// [0, maxPriority], applies its weight, and sums the result per node. A
+// non-ignorable extender's failure fails the whole step; an ignorable one is
+// simply dropped from the combined score.
+func (c *ChainedExtender) Prioritize(pod *api.Pod, nodes *api.NodeList) (*schedulerapi.HostPriorityList, int, error) {
+	ctx := context.Background()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	resultsCh := make(chan extenderPriorityResult, len(c.extenders))
+	errCh := make(chan error, len(c.extenders))
+	var wg sync.WaitGroup
+	for _, extender := range c.extenders {
+		if !extender.IsInterested(pod) {
+			continue
+		}
+		wg.Add(1)
+		go func(ext algorithm.SchedulerExtender) {
+			defer wg.Done()
+			start := time.Now()
+			prioritizedList, weight, err := ext.Prioritize(pod, nodes)
+			metrics.ExtenderLatency.WithLabelValues("prioritize").Observe(time.Since(start).Seconds())
+			if err != nil {
+				metrics.ExtenderError.WithLabelValues("prioritize").Inc()
+				if !ext.IsIgnorable() {
+					errCh <- err
+				}
+				return
+			}
+			resultsCh <- extenderPriorityResult{extender: ext, result: prioritizedList, weight: weight}
+		}(extender)
+	}
+
+	// Close both channels only once every goroutine has actually returned, not
+	// when the timeout below fires -- a goroutine still running past the
+	// timeout may still try to send, and sending on a channel we closed here
+	// would panic.
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+		close(resultsCh)
+		close(errCh)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	// A non-ignorable extender's failure fails the whole step, even if other
+	// extenders are still running past the timeout.
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return nil, 0, err
+		}
+	default:
+	}
+
+	combinedScores := map[string]int{}
+collect:
+	for {
+		select {
+		case res, ok := <-resultsCh:
+			if !ok {
+				break collect
+			}
+			normalized := c.normalize(res.result)
+			for _, hostPriority := range normalized {
+				combinedScores[hostPriority.Host] += hostPriority.Score * res.weight
+			}
+		default:
+			break collect
+		}
+	}
+
+	result := schedulerapi.HostPriorityList{}
+	for _, node := range nodes.Items {
+		result = append(result, schedulerapi.HostPriority{Host: node.Name, Score: combinedScores[node.Name]})
+	}
+	return &result, 1, nil
+}
+
+// normalize rescales an extender's raw scores into [0, maxPriority] so that
+// extenders with different native ranges contribute comparably before
+// weighting.
+func (c *ChainedExtender) normalize(list *schedulerapi.HostPriorityList) schedulerapi.HostPriorityList {
+	if list == nil || len(*list) == 0 {
+		return schedulerapi.HostPriorityList{}
+	}
+
+	min, max := (*list)[0].Score, (*list)[0].Score
+	for _, hostPriority := range *list {
+		if hostPriority.Score < min {
+			min = hostPriority.Score
+		}
+		if hostPriority.Score > max {
+			max = hostPriority.Score
+		}
+	}
+
+	normalized := make(schedulerapi.HostPriorityList, 0, len(*list))
+	spread := max - min
+	for _, hostPriority := range *list {
+		score := c.maxPriority
+		if spread > 0 {
+			score = (hostPriority.Score - min) * c.maxPriority / spread
+		}
+		normalized = append(normalized, schedulerapi.HostPriority{Host: hostPriority.Host, Score: score})
+	}
+	return normalized
+}
+
+// Bind delegates to the first extender configured to bind, matching the
+// scheduler's preference for an extender-provided Bind over the built-in
+// API-server binding.
+func (c *ChainedExtender) Bind(binding *api.Binding) error {
+	for _, extender := range c.extenders {
+		if extender.IsBinder() {
+			return extender.Bind(binding)
+		}
+	}
+	return fmt.Errorf("no extender in the chain implements Bind")
+}
+
+// IsBinder returns true if any extender in the chain can bind pods to nodes.
+func (c *ChainedExtender) IsBinder() bool {
+	for _, extender := range c.extenders {
+		if extender.IsBinder() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsInterested returns true if any extender in the chain is interested in pod,
+// mirroring the per-extender skip Filter/Prioritize/ProcessPreemption already
+// apply to their children.
+func (c *ChainedExtender) IsInterested(pod *api.Pod) bool {
+	for _, extender := range c.extenders {
+		if extender.IsInterested(pod) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIgnorable always returns false: Filter, Prioritize and ProcessPreemption
+// only ever let an error escape the chain when the failing child extender
+// itself was not ignorable, so a caller must never treat that failure as
+// ignorable either.
+func (c *ChainedExtender) IsIgnorable() bool {
+	return false
+}
+
+// SupportsPreemption returns true if any extender in the chain supports
+// preemption.
+func (c *ChainedExtender) SupportsPreemption() bool {
+	for _, extender := range c.extenders {
+		if extender.SupportsPreemption() {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessPreemption runs each preemption-capable extender in sequence,
+// feeding the (possibly narrowed) victim map from one into the next, the same
+// way Filter narrows the node set.
+func (c *ChainedExtender) ProcessPreemption(
+	pod *api.Pod,
+	nodeToVictims map[*api.Node]*schedulerapi.Victims,
+	nodeNameToInfo map[string]*schedulercache.NodeInfo,
+) (map[*api.Node]*schedulerapi.Victims, error) {
+	for _, extender := range c.extenders {
+		if !extender.SupportsPreemption() || !extender.IsInterested(pod) {
+			continue
+		}
+
+		start := time.Now()
+		filtered, err := extender.ProcessPreemption(pod, nodeToVictims, nodeNameToInfo)
+		metrics.ExtenderLatency.WithLabelValues("preempt").Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.ExtenderError.WithLabelValues("preempt").Inc()
+			if extender.IsIgnorable() {
+				continue
+			}
+			return nil, err
+		}
+		nodeToVictims = filtered
+	}
+	return nodeToVictims, nil
+}
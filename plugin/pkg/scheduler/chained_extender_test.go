@@ -0,0 +1,136 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/plugin/pkg/scheduler/algorithm"
+	schedulerapi "k8s.io/kubernetes/plugin/pkg/scheduler/api"
+	"k8s.io/kubernetes/plugin/pkg/scheduler/schedulercache"
+)
+
+// fakeExtender is a minimal algorithm.SchedulerExtender used to drive
+// ChainedExtender without a real HTTP round trip.
+type fakeExtender struct {
+	prioritizeDelay time.Duration
+	prioritizeErr   error
+	ignorable       bool
+	score           int
+}
+
+func (f *fakeExtender) Filter(
+	pod *api.Pod,
+	nodes *api.NodeList,
+	nodeNameToInfo map[string]*schedulercache.NodeInfo,
+) (*api.NodeList, schedulerapi.FailedNodesMap, error) {
+	return nodes, schedulerapi.FailedNodesMap{}, nil
+}
+
+func (f *fakeExtender) Prioritize(pod *api.Pod, nodes *api.NodeList) (*schedulerapi.HostPriorityList, int, error) {
+	if f.prioritizeDelay > 0 {
+		time.Sleep(f.prioritizeDelay)
+	}
+	if f.prioritizeErr != nil {
+		return nil, 0, f.prioritizeErr
+	}
+	result := schedulerapi.HostPriorityList{}
+	for _, node := range nodes.Items {
+		result = append(result, schedulerapi.HostPriority{Host: node.Name, Score: f.score})
+	}
+	return &result, 1, nil
+}
+
+func (f *fakeExtender) Bind(binding *api.Binding) error { return fmt.Errorf("not implemented") }
+func (f *fakeExtender) IsBinder() bool                  { return false }
+func (f *fakeExtender) IsInterested(pod *api.Pod) bool  { return true }
+func (f *fakeExtender) IsIgnorable() bool               { return f.ignorable }
+func (f *fakeExtender) SupportsPreemption() bool        { return false }
+func (f *fakeExtender) ProcessPreemption(
+	pod *api.Pod,
+	nodeToVictims map[*api.Node]*schedulerapi.Victims,
+	nodeNameToInfo map[string]*schedulercache.NodeInfo,
+) (map[*api.Node]*schedulerapi.Victims, error) {
+	return nodeToVictims, nil
+}
+
+func testNodes() *api.NodeList {
+	return &api.NodeList{Items: []api.Node{
+		{ObjectMeta: api.ObjectMeta{Name: "node-1"}},
+		{ObjectMeta: api.ObjectMeta{Name: "node-2"}},
+	}}
+}
+
+// TestChainedExtenderPrioritizeTimeoutDoesNotPanic reproduces the scenario the
+// global timeout exists for: one extender never returns inside the deadline.
+// Prioritize must still return a result instead of panicking with "send on
+// closed channel" when the slow goroutine eventually tries to report in.
+func TestChainedExtenderPrioritizeTimeoutDoesNotPanic(t *testing.T) {
+	fast := &fakeExtender{score: 10}
+	slow := &fakeExtender{prioritizeDelay: 200 * time.Millisecond, score: 1}
+
+	c := NewChainedExtender([]algorithm.SchedulerExtender{fast, slow}, 0, 20*time.Millisecond)
+
+	result, weight, err := c.Prioritize(&api.Pod{}, testNodes())
+	if err != nil {
+		t.Fatalf("Prioritize returned unexpected error: %v", err)
+	}
+	if weight != 1 {
+		t.Errorf("expected weight 1, got %v", weight)
+	}
+	if len(*result) != 2 {
+		t.Fatalf("expected a score for every node, got %+v", result)
+	}
+
+	// Give the slow extender's goroutine time to finish and attempt its
+	// (no longer read) send -- this is where the original code panicked.
+	time.Sleep(300 * time.Millisecond)
+}
+
+// TestChainedExtenderPrioritizeNonIgnorableError verifies a non-ignorable
+// extender's failure fails the whole Prioritize call instead of being
+// silently scored as if it had returned nothing.
+func TestChainedExtenderPrioritizeNonIgnorableError(t *testing.T) {
+	failing := &fakeExtender{prioritizeErr: fmt.Errorf("boom"), ignorable: false}
+
+	c := NewChainedExtender([]algorithm.SchedulerExtender{failing}, 0, 0)
+
+	if _, _, err := c.Prioritize(&api.Pod{}, testNodes()); err == nil {
+		t.Fatal("expected a non-ignorable extender error to propagate, got nil")
+	}
+}
+
+// TestChainedExtenderPrioritizeIgnorableError verifies an ignorable
+// extender's failure is dropped from the combined score rather than failing
+// the whole Prioritize call.
+func TestChainedExtenderPrioritizeIgnorableError(t *testing.T) {
+	failing := &fakeExtender{prioritizeErr: fmt.Errorf("boom"), ignorable: true}
+	fast := &fakeExtender{score: 10}
+
+	c := NewChainedExtender([]algorithm.SchedulerExtender{failing, fast}, 0, 0)
+
+	result, _, err := c.Prioritize(&api.Pod{}, testNodes())
+	if err != nil {
+		t.Fatalf("expected an ignorable extender's error not to propagate, got: %v", err)
+	}
+	if len(*result) != 2 {
+		t.Fatalf("expected a score for every node, got %+v", result)
+	}
+}
@@ -18,30 +18,52 @@ package scheduler
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"sync"
 	"time"
 
 	"k8s.io/kubernetes/pkg/api"
 	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/util/sets"
 	"k8s.io/kubernetes/plugin/pkg/scheduler/algorithm"
 	schedulerapi "k8s.io/kubernetes/plugin/pkg/scheduler/api"
+	"k8s.io/kubernetes/plugin/pkg/scheduler/schedulercache"
 )
 
 const (
 	DefaultExtenderTimeout = 5 * time.Second
+
+	contentTypeJSON     = "application/json"
+	contentTypeProtobuf = "application/vnd.kubernetes.protobuf"
 )
 
+// bufferPool amortizes the per-request encode buffer allocation that
+// dominated extender overhead on large clusters.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
 // HTTPExtender implements the algorithm.SchedulerExtender interface.
 type HTTPExtender struct {
-	extenderURL    string
-	filterVerb     string
-	prioritizeVerb string
-	weight         int
-	apiVersion     string
-	client         *http.Client
+	extenderURL       string
+	filterVerb        string
+	prioritizeVerb    string
+	bindVerb          string
+	preemptVerb       string
+	weight            int
+	apiVersion        string
+	client            *http.Client
+	managedResources  sets.String
+	ignorable         bool
+	nodeCacheCapable  bool
+	contentType       string
+	enableCompression bool
 }
 
 func makeTransport(config *schedulerapi.ExtenderConfig) (http.RoundTripper, error) {
@@ -80,37 +102,126 @@ func NewHTTPExtender(config *schedulerapi.ExtenderConfig, apiVersion string) (al
 		Transport: transport,
 		Timeout:   config.HTTPTimeout,
 	}
+	managedResources := sets.NewString()
+	for _, r := range config.ManagedResources {
+		managedResources.Insert(string(r.Name))
+	}
+	contentType := config.ContentType
+	if contentType == "" {
+		contentType = contentTypeJSON
+	}
+	if contentType != contentTypeJSON {
+		// The extender API types (ExtenderArgs, HostPriorityList, ...) are plain
+		// structs with only json tags; encoding them as protobuf requires
+		// generated .pb.go wire types we don't have yet. Fail fast at
+		// construction rather than silently breaking (or dropping, if
+		// Ignorable) every Filter/Prioritize/Bind/ProcessPreemption call.
+		return nil, fmt.Errorf("unsupported extender content type %q: only %s is currently supported", contentType, contentTypeJSON)
+	}
 	return &HTTPExtender{
-		extenderURL:    config.URLPrefix,
-		apiVersion:     apiVersion,
-		filterVerb:     config.FilterVerb,
-		prioritizeVerb: config.PrioritizeVerb,
-		weight:         config.Weight,
-		client:         client,
+		extenderURL:       config.URLPrefix,
+		apiVersion:        apiVersion,
+		filterVerb:        config.FilterVerb,
+		prioritizeVerb:    config.PrioritizeVerb,
+		bindVerb:          config.BindVerb,
+		preemptVerb:       config.PreemptVerb,
+		weight:            config.Weight,
+		client:            client,
+		managedResources:  managedResources,
+		ignorable:         config.Ignorable,
+		nodeCacheCapable:  config.NodeCacheCapable,
+		contentType:       contentType,
+		enableCompression: config.EnableCompression,
 	}, nil
 }
 
+// IsIgnorable returns whether the scheduler should continue scheduling a pod when
+// this extender returns an error or times out, rather than failing the attempt.
+func (h *HTTPExtender) IsIgnorable() bool {
+	return h.ignorable
+}
+
+// IsInterested returns true if at least one container in pod requests a resource
+// this extender manages, or if the extender does not declare any managed
+// resources, in which case it is assumed to be interested in every pod.
+func (h *HTTPExtender) IsInterested(pod *api.Pod) bool {
+	if h.managedResources.Len() == 0 {
+		return true
+	}
+	if hasManagedResources(h.managedResources, pod.Spec.Containers) {
+		return true
+	}
+	if hasManagedResources(h.managedResources, pod.Spec.InitContainers) {
+		return true
+	}
+	return false
+}
+
+func hasManagedResources(managedResources sets.String, containers []api.Container) bool {
+	for i := range containers {
+		container := &containers[i]
+		for resourceName := range container.Resources.Requests {
+			if managedResources.Has(string(resourceName)) {
+				return true
+			}
+		}
+		for resourceName := range container.Resources.Limits {
+			if managedResources.Has(string(resourceName)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsBinder returns whether this extender is configured for the Bind method.
+func (h *HTTPExtender) IsBinder() bool {
+	return h.bindVerb != ""
+}
+
+// SupportsPreemption returns whether this extender is configured for the
+// preemption verb.
+func (h *HTTPExtender) SupportsPreemption() bool {
+	return h.preemptVerb != ""
+}
+
 // Filter based on extender implemented predicate functions. The filtered list is
-// expected to be a subset of the supplied list.
-func (h *HTTPExtender) Filter(pod *api.Pod, nodes *api.NodeList) (*api.NodeList, error) {
+// expected to be a subset of the supplied list. When the extender is
+// NodeCacheCapable only node names are sent over the wire, and the filtered
+// *api.NodeList is reconstructed locally from nodeNameToInfo.
+func (h *HTTPExtender) Filter(
+	pod *api.Pod,
+	nodes *api.NodeList,
+	nodeNameToInfo map[string]*schedulercache.NodeInfo,
+) (*api.NodeList, schedulerapi.FailedNodesMap, error) {
 	var result schedulerapi.ExtenderFilterResult
 
 	if h.filterVerb == "" {
-		return nodes, nil
+		return nodes, schedulerapi.FailedNodesMap{}, nil
 	}
 
-	args := schedulerapi.ExtenderArgs{
-		Pod:   *pod,
-		Nodes: *nodes,
-	}
+	args := h.buildExtenderArgs(pod, nodes)
 
-	if err := h.send(h.filterVerb, &args, &result); err != nil {
-		return nil, err
+	if err := h.send(h.filterVerb, args, &result); err != nil {
+		return nil, nil, err
 	}
 	if result.Error != "" {
-		return nil, fmt.Errorf(result.Error)
+		return nil, nil, fmt.Errorf(result.Error)
 	}
-	return &result.Nodes, nil
+
+	if h.nodeCacheCapable && result.NodeNames != nil {
+		nodeResult := make([]api.Node, 0, len(*result.NodeNames))
+		for _, nodeName := range *result.NodeNames {
+			nodeInfo, ok := nodeNameToInfo[nodeName]
+			if !ok {
+				return nil, nil, fmt.Errorf("extender %v claims a filtered node %v which is not found in nodeNameToInfo map", h.extenderURL, nodeName)
+			}
+			nodeResult = append(nodeResult, *nodeInfo.Node())
+		}
+		return &api.NodeList{Items: nodeResult}, result.FailedNodes, nil
+	}
+
+	return &result.Nodes, result.FailedNodes, nil
 }
 
 // Prioritize based on extender implemented priority functions. Weight*priority is added
@@ -127,46 +238,242 @@ func (h *HTTPExtender) Prioritize(pod *api.Pod, nodes *api.NodeList) (*scheduler
 		return &result, 0, nil
 	}
 
-	args := schedulerapi.ExtenderArgs{
+	args := h.buildExtenderArgs(pod, nodes)
+
+	if err := h.send(h.prioritizeVerb, args, &result); err != nil {
+		return nil, 0, err
+	}
+	return &result, h.weight, nil
+}
+
+// buildExtenderArgs assembles the ExtenderArgs payload for Filter/Prioritize,
+// sending only node names instead of the full NodeList when the extender has
+// advertised itself as NodeCacheCapable.
+func (h *HTTPExtender) buildExtenderArgs(pod *api.Pod, nodes *api.NodeList) *schedulerapi.ExtenderArgs {
+	if h.nodeCacheCapable {
+		nodeNames := make([]string, 0, len(nodes.Items))
+		for _, node := range nodes.Items {
+			nodeNames = append(nodeNames, node.Name)
+		}
+		return &schedulerapi.ExtenderArgs{
+			Pod:       *pod,
+			NodeNames: &nodeNames,
+		}
+	}
+	return &schedulerapi.ExtenderArgs{
 		Pod:   *pod,
 		Nodes: *nodes,
 	}
+}
 
-	if err := h.send(h.prioritizeVerb, &args, &result); err != nil {
-		return nil, 0, err
+// Bind delegates the action of binding a pod to a node to the extender.
+func (h *HTTPExtender) Bind(binding *api.Binding) error {
+	var result schedulerapi.ExtenderBindingResult
+
+	if !h.IsBinder() {
+		// This shouldn't happen as this extender wouldn't have become a Binder.
+		return fmt.Errorf("unexpected empty bindVerb in extender")
 	}
-	return &result, h.weight, nil
+
+	req := &schedulerapi.ExtenderBindingArgs{
+		PodName:      binding.Name,
+		PodNamespace: binding.Namespace,
+		PodUID:       binding.UID,
+		Node:         binding.Target.Name,
+	}
+
+	if err := h.send(h.bindVerb, req, &result); err != nil {
+		return err
+	}
+	if result.Error != "" {
+		return fmt.Errorf(result.Error)
+	}
+	return nil
+}
+
+// ProcessPreemption gives the extender a chance to filter the set of victims the
+// in-tree preemption algorithm selected for the given pod, e.g. to veto evicting
+// pods that still hold resources the incoming pod needs. It returns the (possibly
+// narrowed) node-to-victims map the scheduler should act on.
+func (h *HTTPExtender) ProcessPreemption(
+	pod *api.Pod,
+	nodeToVictims map[*api.Node]*schedulerapi.Victims,
+	nodeNameToInfo map[string]*schedulercache.NodeInfo,
+) (map[*api.Node]*schedulerapi.Victims, error) {
+	var result schedulerapi.ExtenderPreemptionResult
+
+	if !h.SupportsPreemption() {
+		return nil, fmt.Errorf("preempt verb is not defined for extender %v but run into ProcessPreemption", h.extenderURL)
+	}
+
+	nodeNameToVictims := convertToNodeNameToVictims(nodeToVictims)
+	args := &schedulerapi.ExtenderPreemptionArgs{
+		Pod:                   pod,
+		NodeNameToVictims:     nodeNameToVictims,
+		NodeNameToMetaVictims: convertToMetaVictims(nodeNameToVictims),
+	}
+
+	if err := h.send(h.preemptVerb, args, &result); err != nil {
+		return nil, err
+	}
+
+	return convertToNodeToVictims(result.NodeNameToMetaVictims, nodeNameToInfo)
+}
+
+// convertToNodeNameToVictims re-keys a node-to-victims map by node name so it can
+// be marshaled to the extender without shipping the full *api.Node objects.
+func convertToNodeNameToVictims(
+	nodeToVictims map[*api.Node]*schedulerapi.Victims,
+) map[string]*schedulerapi.Victims {
+	nodeNameToVictims := map[string]*schedulerapi.Victims{}
+	for node, victims := range nodeToVictims {
+		nodeNameToVictims[node.Name] = victims
+	}
+	return nodeNameToVictims
 }
 
-// Helper function to send messages to the extender
+// convertToMetaVictims strips each Victims down to the lightweight MetaPod/
+// MetaVictims representation that is actually sent over the wire.
+func convertToMetaVictims(
+	nodeNameToVictims map[string]*schedulerapi.Victims,
+) map[string]*schedulerapi.MetaVictims {
+	nodeNameToMetaVictims := map[string]*schedulerapi.MetaVictims{}
+	for node, victims := range nodeNameToVictims {
+		metaVictims := &schedulerapi.MetaVictims{
+			NumPDBViolations: victims.NumPDBViolations,
+		}
+		for _, pod := range victims.Pods {
+			metaPod := &schedulerapi.MetaPod{
+				UID: string(pod.UID),
+			}
+			metaVictims.Pods = append(metaVictims.Pods, metaPod)
+		}
+		nodeNameToMetaVictims[node] = metaVictims
+	}
+	return nodeNameToMetaVictims
+}
+
+// convertToNodeToVictims reconstructs the node-keyed victims map the scheduler
+// operates on from the extender's metadata-only reply, using the scheduler's
+// own node-info cache to resolve node names and pod UIDs back to objects.
+func convertToNodeToVictims(
+	nodeNameToMetaVictims map[string]*schedulerapi.MetaVictims,
+	nodeNameToInfo map[string]*schedulercache.NodeInfo,
+) (map[*api.Node]*schedulerapi.Victims, error) {
+	nodeToVictims := map[*api.Node]*schedulerapi.Victims{}
+	for nodeName, metaVictims := range nodeNameToMetaVictims {
+		nodeInfo, ok := nodeNameToInfo[nodeName]
+		if !ok {
+			return nil, fmt.Errorf("extender returned unknown node %v in ProcessPreemption", nodeName)
+		}
+		victims := &schedulerapi.Victims{
+			NumPDBViolations: metaVictims.NumPDBViolations,
+		}
+		for _, metaPod := range metaVictims.Pods {
+			pod, err := findPodByUID(nodeInfo, metaPod.UID)
+			if err != nil {
+				return nil, err
+			}
+			victims.Pods = append(victims.Pods, pod)
+		}
+		nodeToVictims[nodeInfo.Node()] = victims
+	}
+	return nodeToVictims, nil
+}
+
+// findPodByUID locates the pod with the given UID among those running on nodeInfo.
+func findPodByUID(nodeInfo *schedulercache.NodeInfo, uid string) (*api.Pod, error) {
+	for _, pod := range nodeInfo.Pods() {
+		if string(pod.UID) == uid {
+			return pod, nil
+		}
+	}
+	return nil, fmt.Errorf("extender returned unknown pod uid %v in ProcessPreemption", uid)
+}
+
+// send marshals args using the extender's configured content type, POSTs it to
+// action, and unmarshals the response into result. It reuses a pooled buffer
+// for encoding and transparently gzips the request body/accepts a gzipped
+// response when the extender has EnableCompression set.
 func (h *HTTPExtender) send(action string, args interface{}, result interface{}) error {
-	out, err := json.Marshal(args)
-	if err != nil {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	if err := h.encodeBody(buf, args); err != nil {
 		return err
 	}
 
+	ctx := context.Background()
+	if h.client.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.client.Timeout)
+		defer cancel()
+	}
+
 	url := h.extenderURL + "/" + h.apiVersion + "/" + action
 
-	req, err := http.NewRequest("POST", url, bytes.NewReader(out))
+	var body io.Reader = buf
+	if h.enableCompression {
+		gzipped := bufferPool.Get().(*bytes.Buffer)
+		gzipped.Reset()
+		defer bufferPool.Put(gzipped)
+
+		gz := gzip.NewWriter(gzipped)
+		if _, err := gz.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		body = gzipped
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", h.contentType)
+	req.Header.Set("Accept", h.contentType)
+	if h.enableCompression {
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
 
 	resp, err := h.client.Do(req)
 	if err != nil {
 		return err
 	}
-
 	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+
+	respBody := resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gzr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return err
+		}
+		defer gzr.Close()
+		respBody = gzr
 	}
 
-	if err := json.Unmarshal(body, result); err != nil {
+	respOut, err := ioutil.ReadAll(respBody)
+	if err != nil {
 		return err
 	}
-	return nil
+
+	return h.decodeBody(respOut, result)
+}
+
+// encodeBody encodes args into buf. JSON is the only content type
+// NewHTTPExtender currently accepts; see the contentType validation there.
+func (h *HTTPExtender) encodeBody(buf *bytes.Buffer, args interface{}) error {
+	return json.NewEncoder(buf).Encode(args)
+}
+
+// decodeBody decodes a response body into result. JSON is the only content
+// type NewHTTPExtender currently accepts; see the contentType validation
+// there.
+func (h *HTTPExtender) decodeBody(body []byte, result interface{}) error {
+	return json.Unmarshal(body, result)
 }
@@ -0,0 +1,130 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	schedulerapi "k8s.io/kubernetes/plugin/pkg/scheduler/api"
+)
+
+// newTestExtender builds an HTTPExtender talking to server with the given
+// EnableCompression setting, bypassing NewHTTPExtender's URL/verb plumbing so
+// the test can drive send() directly.
+func newTestExtender(server *httptest.Server, enableCompression bool) *HTTPExtender {
+	return &HTTPExtender{
+		extenderURL:       server.URL,
+		apiVersion:        "v1",
+		contentType:       contentTypeJSON,
+		enableCompression: enableCompression,
+		client:            server.Client(),
+	}
+}
+
+// TestSendJSONRoundTrip verifies that send() encodes args as JSON, the fake
+// extender server can decode and respond, and send() decodes the response
+// back into result -- the round trip the earlier broken protobuf path never
+// had a test for.
+func TestSendJSONRoundTrip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != contentTypeJSON {
+			t.Errorf("unexpected Content-Type: %v", ct)
+		}
+		var args schedulerapi.HostPriorityList
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("failed to read request body: %v", err)
+		}
+		if err := json.Unmarshal(body, &args); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		w.Header().Set("Content-Type", contentTypeJSON)
+		json.NewEncoder(w).Encode(args)
+	}))
+	defer server.Close()
+
+	h := newTestExtender(server, false)
+
+	args := schedulerapi.HostPriorityList{{Host: "node-1", Score: 5}}
+	var result schedulerapi.HostPriorityList
+	if err := h.send("prioritize", &args, &result); err != nil {
+		t.Fatalf("send() returned error: %v", err)
+	}
+	if len(result) != 1 || result[0].Host != "node-1" || result[0].Score != 5 {
+		t.Errorf("unexpected round-tripped result: %+v", result)
+	}
+}
+
+// TestSendCompressedRoundTrip verifies send() gzips the request body and
+// transparently gunzips a gzipped response when EnableCompression is set.
+func TestSendCompressedRoundTrip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if enc := r.Header.Get("Content-Encoding"); enc != "gzip" {
+			t.Fatalf("expected gzipped request body, got Content-Encoding=%v", enc)
+		}
+		gzr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatalf("request body is not valid gzip: %v", err)
+		}
+		defer gzr.Close()
+		body, err := ioutil.ReadAll(gzr)
+		if err != nil {
+			t.Fatalf("failed to read gzipped request body: %v", err)
+		}
+		var args schedulerapi.HostPriorityList
+		if err := json.Unmarshal(body, &args); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+
+		w.Header().Set("Content-Type", contentTypeJSON)
+		w.Header().Set("Content-Encoding", "gzip")
+		gzw := gzip.NewWriter(w)
+		defer gzw.Close()
+		json.NewEncoder(gzw).Encode(args)
+	}))
+	defer server.Close()
+
+	h := newTestExtender(server, true)
+
+	args := schedulerapi.HostPriorityList{{Host: "node-1", Score: 7}}
+	var result schedulerapi.HostPriorityList
+	if err := h.send("prioritize", &args, &result); err != nil {
+		t.Fatalf("send() returned error: %v", err)
+	}
+	if len(result) != 1 || result[0].Host != "node-1" || result[0].Score != 7 {
+		t.Errorf("unexpected round-tripped result: %+v", result)
+	}
+}
+
+// TestNewHTTPExtenderRejectsProtobuf verifies that requesting the protobuf
+// content type fails fast at construction with a clear error, instead of
+// silently breaking (or being dropped, if Ignorable) on every subsequent
+// Filter/Prioritize/Bind/ProcessPreemption call.
+func TestNewHTTPExtenderRejectsProtobuf(t *testing.T) {
+	config := &schedulerapi.ExtenderConfig{
+		URLPrefix:   "http://localhost",
+		ContentType: contentTypeProtobuf,
+	}
+	if _, err := NewHTTPExtender(config, "v1"); err == nil {
+		t.Fatal("expected NewHTTPExtender to reject an unsupported content type, got nil error")
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SchedulerSubsystem is the Prometheus subsystem all scheduler metrics are
+// registered under.
+const SchedulerSubsystem = "scheduler"
+
+var (
+	// ExtenderLatency reports per-call latency for scheduler extender requests,
+	// broken down by verb (filter, prioritize, preempt), so a slow extender in a
+	// chain shows up without having to instrument every extender implementation.
+	ExtenderLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: SchedulerSubsystem,
+			Name:      "extender_latency_seconds",
+			Help:      "Latency in seconds of scheduler extender calls, broken down by verb.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"verb"},
+	)
+
+	// ExtenderError counts scheduler extender calls that returned an error,
+	// broken down by verb (filter, prioritize, preempt).
+	ExtenderError = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: SchedulerSubsystem,
+			Name:      "extender_errors_total",
+			Help:      "Number of scheduler extender calls that returned an error, broken down by verb.",
+		},
+		[]string{"verb"},
+	)
+)
+
+var registerMetrics sync.Once
+
+// Register registers the scheduler metrics with the default Prometheus
+// registry. It is safe to call more than once.
+func Register() {
+	registerMetrics.Do(func() {
+		prometheus.MustRegister(ExtenderLatency)
+		prometheus.MustRegister(ExtenderError)
+	})
+}